@@ -0,0 +1,62 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// GenerateBundleTo builds the site and streams a zip archive of
+// Config.PublishDir into w, so large sites don't have to be buffered in
+// memory.
+func (s *Site) GenerateBundleTo(w io.Writer) error {
+	if err := s.Build(); err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+
+	err := filepath.Walk(s.Config.PublishDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.Config.PublishDir, path)
+		if err != nil {
+			return err
+		}
+
+		entry, err := zw.Create(rel)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(entry, f)
+		return err
+	})
+	if err != nil {
+		zw.Close()
+		return err
+	}
+
+	return zw.Close()
+}
+
+// GenerateBundle builds the site and returns a zip archive of the generated
+// pages. It's a thin wrapper around GenerateBundleTo for callers that want
+// the whole archive in memory.
+func (s *Site) GenerateBundle() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := s.GenerateBundleTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
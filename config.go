@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// configFileSearchOrder lists the site config filenames SiteStore.Find
+// searches for, in the order Hugo itself looks for them.
+var configFileSearchOrder = []string{"config.toml", "config.yaml", "config.yml", "config.json"}
+
+// configFileFormats maps a config filename to the format used to decode it.
+var configFileFormats = map[string]string{
+	"config.toml": "toml",
+	"config.yaml": "yaml",
+	"config.yml":  "yaml",
+	"config.json": "json",
+}
+
+// configFormatExt maps a format back to its canonical file extension.
+var configFormatExt = map[string]string{
+	"toml": "toml",
+	"yaml": "yaml",
+	"json": "json",
+}
+
+// findConfigFile searches sitePath for a Hugo site config file and reports
+// both its path and the format it should be decoded with. It returns an
+// empty path if none of the supported formats are present.
+func findConfigFile(sitePath string) (path string, format string) {
+	for _, name := range configFileSearchOrder {
+		candidate := filepath.Join(sitePath, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, configFileFormats[name]
+		}
+	}
+	return "", ""
+}
+
+// configFileName returns the canonical config filename for format, e.g.
+// "config.yaml" for "yaml".
+func configFileName(format string) string {
+	return fmt.Sprintf("config.%s", configFormatExt[format])
+}
+
+// decodeSiteConfig decodes the config file at path into config, dispatching
+// on format the same way Hugo itself discovers configuration.
+func decodeSiteConfig(path string, format string, config *SiteConfig) error {
+	switch format {
+	case "toml":
+		_, err := toml.DecodeFile(path, config)
+		return err
+	case "yaml":
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return yaml.Unmarshal(data, config)
+	case "json":
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, config)
+	default:
+		return fmt.Errorf("unsupported config format '%s'", format)
+	}
+}
+
+// encodeSiteConfig encodes config in the given format.
+func encodeSiteConfig(format string, config *SiteConfig) ([]byte, error) {
+	switch format {
+	case "toml":
+		buf := new(bytes.Buffer)
+		if err := toml.NewEncoder(buf).Encode(config); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "yaml":
+		return yaml.Marshal(config)
+	case "json":
+		return json.MarshalIndent(config, "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported config format '%s'", format)
+	}
+}
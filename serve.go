@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	defaultServePortRangeStart = 1313
+	defaultServePortRangeEnd   = 1400
+	serveLogRingSize           = 500
+
+	// portFreeTimeout bounds how long Stop waits for the kernel to report
+	// a killed subprocess's port as free again.
+	portFreeTimeout = 2 * time.Second
+)
+
+// ServeOptions configures SiteStore.Serve.
+type ServeOptions struct {
+	// BuildDrafts passes --buildDrafts to hugo serve.
+	BuildDrafts bool
+
+	// Bind is the address hugo serve listens on. Defaults to "127.0.0.1".
+	Bind string
+
+	// Port is the TCP port to serve on. 0 picks a free port from the
+	// store's configured range.
+	Port int
+}
+
+// ServeHandle controls a single running `hugo serve` subprocess.
+type ServeHandle struct {
+	site *Site
+	opts ServeOptions
+	port int
+	logs *serveLogRing
+
+	mu  sync.Mutex
+	cmd *exec.Cmd
+}
+
+// URL returns the address hugo serve is reachable at.
+func (h *ServeHandle) URL() string {
+	bind := h.opts.Bind
+	if bind == "" {
+		bind = "127.0.0.1"
+	}
+	return fmt.Sprintf("http://%s:%d", bind, h.port)
+}
+
+// Logs returns the subprocess's combined stdout/stderr, oldest first.
+func (h *ServeHandle) Logs() []string {
+	return h.logs.Lines()
+}
+
+// Stop terminates the subprocess, reaps it so it doesn't linger as a
+// zombie, and waits for its port to actually be released before returning
+// (the port stays reserved on the owning SiteStore either way, so Restart
+// can safely reclaim it).
+func (h *ServeHandle) Stop() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cmd == nil || h.cmd.Process == nil {
+		return nil
+	}
+
+	if err := h.cmd.Process.Kill(); err != nil {
+		return err
+	}
+
+	// A killed process exits via signal, which Wait reports as an error;
+	// that's expected, not a failure to stop.
+	h.cmd.Wait()
+	h.cmd = nil
+
+	return waitForPortFree(h.port, portFreeTimeout)
+}
+
+// Restart stops and relaunches the subprocess on the same port.
+func (h *ServeHandle) Restart() error {
+	if err := h.Stop(); err != nil {
+		return err
+	}
+	return h.start()
+}
+
+// start launches the `hugo serve` subprocess and records it on the handle.
+func (h *ServeHandle) start() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	bind := h.opts.Bind
+	if bind == "" {
+		bind = "127.0.0.1"
+	}
+
+	args := []string{
+		"serve",
+		"--source", h.site.Config.SitePath,
+		"--port", fmt.Sprintf("%d", h.port),
+		"--bind", bind,
+	}
+	if h.opts.BuildDrafts {
+		args = append(args, "--buildDrafts")
+	}
+
+	cmd := exec.Command("hugo", args...)
+	cmd.Stdout = h.logs
+	cmd.Stderr = h.logs
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	h.cmd = cmd
+	return nil
+}
+
+// serveLogRing is an io.Writer-backed ring buffer that keeps a bounded tail
+// of a ServeHandle's combined stdout/stderr.
+type serveLogRing struct {
+	mu    sync.Mutex
+	lines []string
+	max   int
+}
+
+func newServeLogRing(max int) *serveLogRing {
+	return &serveLogRing{max: max}
+}
+
+func (r *serveLogRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scanner := bufio.NewScanner(bytes.NewReader(p))
+	for scanner.Scan() {
+		r.lines = append(r.lines, scanner.Text())
+	}
+	if len(r.lines) > r.max {
+		r.lines = r.lines[len(r.lines)-r.max:]
+	}
+	return len(p), nil
+}
+
+func (r *serveLogRing) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lines := make([]string, len(r.lines))
+	copy(lines, r.lines)
+	return lines
+}
+
+// Serve launches `hugo serve` for the site with the given id as a managed
+// subprocess and returns a handle to control it. The handle is tracked on
+// the store so SiteStore.StopAll can shut it down cleanly.
+func (s *SiteStore) Serve(id string, opts ServeOptions) (*ServeHandle, error) {
+	site := s.Find(id)
+	if site == nil {
+		return nil, fmt.Errorf("site '%s' doesn't exist", id)
+	}
+
+	port := opts.Port
+	if port == 0 {
+		allocated, err := s.allocatePort()
+		if err != nil {
+			return nil, err
+		}
+		port = allocated
+	} else {
+		s.mu.Lock()
+		s.reservedPorts[port] = true
+		s.mu.Unlock()
+	}
+
+	handle := &ServeHandle{
+		site: site,
+		opts: opts,
+		port: port,
+		logs: newServeLogRing(serveLogRingSize),
+	}
+	if err := handle.start(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.serveHandles[id] = handle
+	s.mu.Unlock()
+
+	return handle, nil
+}
+
+// ServeAll brings up a hugo serve instance, each on a distinct port, for
+// every site found under SitePath.
+func (s *SiteStore) ServeAll(opts ServeOptions) ([]*ServeHandle, error) {
+	entries, err := ioutil.ReadDir(s.SitePath)
+	if err != nil {
+		return nil, err
+	}
+
+	handles := make([]*ServeHandle, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		handle, err := s.Serve(entry.Name(), opts)
+		if err != nil {
+			log.Errorf("Couldn't serve site '%s': %s", entry.Name(), err.Error())
+			continue
+		}
+		handles = append(handles, handle)
+	}
+
+	return handles, nil
+}
+
+// StopAll stops every hugo serve subprocess tracked by the store and
+// releases their reserved ports. Unlike a single Stop (which keeps its port
+// reserved so Restart can reclaim it), StopAll is full teardown - wire it up
+// to a shutdown hook - so nothing is left behind for the store to hand back
+// out to a later Serve call.
+func (s *SiteStore) StopAll() {
+	s.mu.Lock()
+	handles := s.serveHandles
+	s.serveHandles = make(map[string]*ServeHandle)
+	s.reservedPorts = make(map[int]bool)
+	s.mu.Unlock()
+
+	for id, handle := range handles {
+		if err := handle.Stop(); err != nil {
+			log.Errorf("Couldn't stop site '%s': %s", id, err.Error())
+		}
+	}
+}
+
+// allocatePort returns a free TCP port from the store's configured range
+// and reserves it immediately, so a subsequent allocation (e.g. the next
+// iteration of ServeAll) doesn't hand out the same port before this one's
+// hugo serve subprocess has had a chance to actually bind it.
+func (s *SiteStore) allocatePort() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start, end := s.servePortRange[0], s.servePortRange[1]
+	for port := start; port <= end; port++ {
+		if s.reservedPorts[port] {
+			continue
+		}
+		if !portIsFree(port) {
+			continue
+		}
+		s.reservedPorts[port] = true
+		return port, nil
+	}
+	return 0, fmt.Errorf("no free port available in range %d-%d", start, end)
+}
+
+func portIsFree(port int) bool {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
+
+// waitForPortFree polls port until the kernel reports it free or timeout
+// elapses, so a restart doesn't race the just-killed process's socket
+// teardown.
+func waitForPortFree(port int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if portIsFree(port) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("port %d still in use after %s", port, timeout)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// SiteConfig holds the Hugo configuration.
+type SiteConfig struct {
+	ID           string            `toml:"-" yaml:"-" json:"-"`
+	ThemeURL     map[string]string `toml:"-" yaml:"-" json:"-"`
+	SitePath     string            `toml:"-" yaml:"-" json:"-"`
+	ConfigFormat string            `toml:"-" yaml:"-" json:"-"`
+
+	// ConfigFile is the exact filename the config was discovered under
+	// (e.g. "config.yml", not the canonical "config.yaml" for its format),
+	// so SaveConfig can round-trip back to it instead of a different
+	// filename for the same format.
+	ConfigFile string `toml:"-" yaml:"-" json:"-"`
+
+	ThemesDir  string `toml:"themesDir" yaml:"themesDir" json:"themesDir"`
+	ContentDir string `toml:"contentDir" yaml:"contentDir" json:"contentDir"`
+	LayoutDir  string `toml:"layoutDir" yaml:"layoutDir" json:"layoutDir"`
+	PublishDir string `toml:"publishDir" yaml:"publishDir" json:"publishDir"`
+
+	BaseURL      string   `toml:"baseURL" yaml:"baseURL" json:"baseURL"`
+	LanguageCode string   `toml:"languageCode" yaml:"languageCode" json:"languageCode"`
+	Title        string   `toml:"title" yaml:"title" json:"title"`
+	Theme        []string `toml:"theme" yaml:"theme" json:"theme"`
+
+	Params        map[string]interface{} `toml:"params,omitempty" yaml:"params,omitempty" json:"params,omitempty"`
+	Menu          map[string]interface{} `toml:"menu,omitempty" yaml:"menu,omitempty" json:"menu,omitempty"`
+	OutputFormats map[string]interface{} `toml:"outputFormats,omitempty" yaml:"outputFormats,omitempty" json:"outputFormats,omitempty"`
+	MediaTypes    map[string]interface{} `toml:"mediaTypes,omitempty" yaml:"mediaTypes,omitempty" json:"mediaTypes,omitempty"`
+}
+
+// Site represents a Hugo site, useful methods will be implemented here.
+type Site struct {
+	Config        *SiteConfig
+	ResolvedTheme *ResolvedTheme
+}
+
+// Build triggers a Hugo build.
+func (s *Site) Build() (err error) {
+	cmd := exec.Command("hugo")
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Dir = s.Config.SitePath
+	err = cmd.Run()
+	if err != nil {
+		log.Error(stderr.String())
+		return err
+	}
+	log.Info(stdout.String())
+	return nil
+}
+
+// SiteStore wraps useful methods for sites.
+type SiteStore struct {
+	Sites      map[string]*Site
+	SitePath   string
+	ThemeStore *ThemeStore
+
+	// servePortRange bounds the ports SiteStore.Serve allocates
+	// automatically.
+	servePortRange [2]int
+
+	mu            sync.Mutex
+	serveHandles  map[string]*ServeHandle
+	reservedPorts map[int]bool
+}
+
+// NewSiteStore initializes a new site store.
+func NewSiteStore(themeStore *ThemeStore) *SiteStore {
+	siteStore := &SiteStore{
+		Sites:          make(map[string]*Site),
+		SitePath:       defaultSiteStorePath,
+		ThemeStore:     themeStore,
+		servePortRange: [2]int{defaultServePortRangeStart, defaultServePortRangeEnd},
+		serveHandles:   make(map[string]*ServeHandle),
+		reservedPorts:  make(map[int]bool),
+	}
+	_, err := ioutil.ReadDir(siteStore.SitePath)
+	if err != nil {
+		log.Warning("Site store path doesn't exist, creating.")
+		os.Mkdir(siteStore.SitePath, 0700)
+	}
+	return siteStore
+}
+
+// Create creates a new site.
+func (s *SiteStore) Create(config *SiteConfig) (site *Site, err error) {
+	site = &Site{
+		Config: config,
+	}
+
+	// Resolve every theme in order, fetching whichever ones aren't in the
+	// store yet. The leftmost theme takes precedence over the rest.
+	resolved, err := s.ThemeStore.ResolveThemes(config.Theme, config.ThemeURL)
+	if err != nil {
+		return nil, err
+	}
+	site.ResolvedTheme = resolved
+
+	// Layer each theme's own config.toml/yaml/json onto the project's
+	// config: project values always win, themes only fill gaps.
+	if err := mergeThemeConfig(config, resolved, s.ThemeStore); err != nil {
+		return nil, err
+	}
+
+	sitePath := filepath.Join(s.SitePath, config.ID)
+
+	config.ThemesDir = s.ThemeStore.StorePath
+	config.ContentDir = filepath.Join(sitePath, "content")
+	config.LayoutDir = filepath.Join(sitePath, "layout")
+	config.PublishDir = filepath.Join(sitePath, "public")
+
+	config.SitePath = sitePath
+	if config.ConfigFormat == "" {
+		config.ConfigFormat = "toml"
+	}
+	if config.ConfigFile == "" {
+		config.ConfigFile = configFileName(config.ConfigFormat)
+	}
+
+	data, err := encodeSiteConfig(config.ConfigFormat, site.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	configFilename := fmt.Sprintf("%s.%s", config.ID, configFormatExt[config.ConfigFormat])
+	configPath := filepath.Join(s.SitePath, configFilename)
+	err = ioutil.WriteFile(configPath, data, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("hugo", "new", "--config", configPath, config.ID)
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		log.Error(stderr.String())
+		return nil, err
+	}
+
+	log.Info("Output:")
+	log.Info(stdout.String())
+
+	newConfigPath := filepath.Join(s.SitePath, config.ID, config.ConfigFile)
+	os.Rename(configPath, newConfigPath)
+
+	// Layer i18n and data from every theme onto the freshly scaffolded
+	// site; see mergeThemeAssets for why layouts/archetypes/static aren't
+	// handled here too.
+	if err := mergeThemeAssets(sitePath, resolved); err != nil {
+		log.Errorf("Couldn't merge theme assets: %s", err.Error())
+		return nil, err
+	}
+
+	return site, nil
+}
+
+// Find returns the site associated with the given ID. It discovers the
+// site's config file the same way Hugo does, trying config.toml,
+// config.yaml, config.yml and config.json in turn.
+func (s *SiteStore) Find(id string) (site *Site) {
+	sitePath := filepath.Join(s.SitePath, id)
+	_, err := ioutil.ReadDir(sitePath)
+	if err != nil {
+		log.Errorf("Site path '%s' doesn't exist", sitePath)
+		return nil
+	}
+
+	configPath, format := findConfigFile(sitePath)
+	if configPath == "" {
+		log.Errorf("No config file found under '%s'", sitePath)
+		return nil
+	}
+
+	var config SiteConfig
+	if err := decodeSiteConfig(configPath, format, &config); err != nil {
+		log.Errorf("Couldn't decode %s config: %s", format, err.Error())
+		return nil
+	}
+	config.SitePath = sitePath
+	config.ConfigFormat = format
+	config.ConfigFile = filepath.Base(configPath)
+	site = &Site{
+		Config: &config,
+	}
+	return site
+}
+
+// Reload re-resolves the site's themes, re-merges their config into the
+// site's own configuration and rewrites its config file. Call this after
+// ThemeStore.Sync has pulled down changes to one of the site's themes.
+func (s *Site) Reload(themeStore *ThemeStore) error {
+	resolved, err := themeStore.ResolveThemes(s.Config.Theme, s.Config.ThemeURL)
+	if err != nil {
+		return err
+	}
+	s.ResolvedTheme = resolved
+
+	if err := mergeThemeConfig(s.Config, resolved, themeStore); err != nil {
+		return err
+	}
+
+	return s.SaveConfig()
+}
+
+// SaveConfig writes the site's current in-memory configuration back to
+// disk, round-tripping through whichever format it was originally
+// discovered in, so a user's hand-edited config.yaml or config.json isn't
+// clobbered with a config.toml.
+func (s *Site) SaveConfig() error {
+	format := s.Config.ConfigFormat
+	if format == "" {
+		format = "toml"
+	}
+
+	data, err := encodeSiteConfig(format, s.Config)
+	if err != nil {
+		return err
+	}
+
+	configFile := s.Config.ConfigFile
+	if configFile == "" {
+		configFile = configFileName(format)
+	}
+
+	configPath := filepath.Join(s.Config.SitePath, configFile)
+	return ioutil.WriteFile(configPath, data, 0700)
+}
+
+// Render generates the pages.
+func (s *Site) Render() (err error) {
+	return nil
+}
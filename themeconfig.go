@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// ThemeConfig holds the subset of a theme's own config.toml/yaml/json that
+// SiteStore.Create and Site.Reload merge into a site's effective
+// configuration.
+type ThemeConfig struct {
+	Params        map[string]interface{}
+	Menu          map[string]interface{}
+	OutputFormats map[string]interface{}
+	MediaTypes    map[string]interface{}
+}
+
+// themeConfigNames lists the theme config filenames considered, in the
+// order they're searched for.
+var themeConfigNames = []string{"config.toml", "config.yaml", "config.yml", "config.json"}
+
+// LoadConfig parses the named theme's own config.toml/yaml/json, if it has
+// one, and returns the sections SiteStore.Create knows how to merge.
+func (s *ThemeStore) LoadConfig(name string) (*ThemeConfig, error) {
+	theme := s.Find(name)
+	if theme == nil {
+		return nil, fmt.Errorf("theme '%s' not found", name)
+	}
+
+	path := findThemeConfigFile(theme.Path)
+	if path == "" {
+		return &ThemeConfig{}, nil
+	}
+
+	values, err := decodeDataFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ThemeConfig{}
+	if params, ok := values["params"].(map[string]interface{}); ok {
+		config.Params = params
+	}
+	if menu, ok := values["menu"].(map[string]interface{}); ok {
+		config.Menu = menu
+	}
+	if outputFormats, ok := values["outputFormats"].(map[string]interface{}); ok {
+		config.OutputFormats = outputFormats
+	}
+	if mediaTypes, ok := values["mediaTypes"].(map[string]interface{}); ok {
+		config.MediaTypes = mediaTypes
+	}
+
+	return config, nil
+}
+
+// findThemeConfigFile returns the first config file found directly under
+// themePath, or "" if the theme doesn't ship one.
+func findThemeConfigFile(themePath string) string {
+	for _, name := range themeConfigNames {
+		candidate := filepath.Join(themePath, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// mergeThemeConfig merges every resolved theme's own config into config, in
+// order. Project values always win: a section is only filled in where the
+// project left it unset, and within that, the leftmost theme wins.
+func mergeThemeConfig(config *SiteConfig, resolved *ResolvedTheme, themeStore *ThemeStore) error {
+	if config.Params == nil {
+		config.Params = make(map[string]interface{})
+	}
+	if config.Menu == nil {
+		config.Menu = make(map[string]interface{})
+	}
+	if config.OutputFormats == nil {
+		config.OutputFormats = make(map[string]interface{})
+	}
+	if config.MediaTypes == nil {
+		config.MediaTypes = make(map[string]interface{})
+	}
+
+	for _, name := range resolved.Names {
+		themeConfig, err := themeStore.LoadConfig(name)
+		if err != nil {
+			return err
+		}
+
+		mergeThemeParams(config.Params, name, themeConfig.Params)
+		mergeThemeMenus(config.Menu, themeConfig.Menu)
+		mergeThemeMapFallback(config.OutputFormats, themeConfig.OutputFormats)
+		mergeThemeMapFallback(config.MediaTypes, themeConfig.MediaTypes)
+	}
+
+	return nil
+}
+
+// mergeThemeParams namespaces a theme's params under params.<themeName> and
+// additionally uses them as fallbacks for any top-level param the project
+// hasn't defined.
+func mergeThemeParams(projectParams map[string]interface{}, themeName string, themeParams map[string]interface{}) {
+	if len(themeParams) == 0 {
+		return
+	}
+	if _, exists := projectParams[themeName]; !exists {
+		projectParams[themeName] = themeParams
+	}
+	mergeThemeMapFallback(projectParams, themeParams)
+}
+
+// mergeThemeMapFallback copies every key from theme into project that
+// project doesn't already define.
+func mergeThemeMapFallback(project map[string]interface{}, theme map[string]interface{}) {
+	for k, v := range theme {
+		if _, exists := project[k]; !exists {
+			project[k] = v
+		}
+	}
+}
+
+// asMenuEntries normalizes a decoded menu value into a slice of entry maps.
+// BurntSushi/toml decodes an array-of-tables like [[menu.main]] into
+// []map[string]interface{}, while the yaml.v3/encoding-json decoders used
+// for the other formats decode the equivalent list into []interface{} of
+// maps; both need to merge the same way.
+func asMenuEntries(raw interface{}) []map[string]interface{} {
+	switch v := raw.(type) {
+	case []map[string]interface{}:
+		return v
+	case []interface{}:
+		entries := make([]map[string]interface{}, 0, len(v))
+		for _, e := range v {
+			if entry, ok := e.(map[string]interface{}); ok {
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	default:
+		return nil
+	}
+}
+
+// mergeThemeMenus adds a theme's menu entries to the project's menus,
+// skipping any entry the project already has. Entries are deduped by
+// identifier where present, and by deep equality otherwise, so re-running
+// the merge over an already-merged config (as Site.Reload does) doesn't
+// pile up duplicate identifier-less entries on every reload.
+func mergeThemeMenus(projectMenus map[string]interface{}, themeMenus map[string]interface{}) {
+	for menuName, themeEntriesRaw := range themeMenus {
+		themeEntries := asMenuEntries(themeEntriesRaw)
+		if len(themeEntries) == 0 {
+			continue
+		}
+
+		projectEntries := asMenuEntries(projectMenus[menuName])
+		existingIDs := make(map[string]bool, len(projectEntries))
+		for _, entry := range projectEntries {
+			if id, ok := entry["identifier"].(string); ok {
+				existingIDs[id] = true
+			}
+		}
+
+		for _, entry := range themeEntries {
+			if id, ok := entry["identifier"].(string); ok {
+				if existingIDs[id] {
+					continue
+				}
+			} else if containsMenuEntry(projectEntries, entry) {
+				continue
+			}
+			projectEntries = append(projectEntries, entry)
+		}
+
+		projectMenus[menuName] = projectEntries
+	}
+}
+
+// containsMenuEntry reports whether entries already contains one deeply
+// equal to entry, for deduping menu entries that have no identifier to key
+// on.
+func containsMenuEntry(entries []map[string]interface{}, entry map[string]interface{}) bool {
+	for _, existing := range entries {
+		if reflect.DeepEqual(existing, entry) {
+			return true
+		}
+	}
+	return false
+}